@@ -0,0 +1,58 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// traceDataVisitor is called for each tuple of Resource, InstrumentationLibrary, and Span.
+// Returning false stops the traversal.
+type traceDataVisitor interface {
+	visit(resource pdata.Resource, instrumentationLibrary pdata.InstrumentationLibrary, span pdata.Span) (ok bool)
+}
+
+// Accept walks traceData calling v.visit for every span, stopping early if visit returns false.
+func Accept(traceData pdata.Traces, v traceDataVisitor) {
+	resourceSpans := traceData.ResourceSpans()
+	for i := 0; i < resourceSpans.Len(); i++ {
+		rs := resourceSpans.At(i)
+		if rs.IsNil() {
+			continue
+		}
+
+		resource := rs.Resource()
+		ilss := rs.InstrumentationLibrarySpans()
+		for j := 0; j < ilss.Len(); j++ {
+			ils := ilss.At(j)
+			if ils.IsNil() {
+				continue
+			}
+
+			instrumentationLibrary := ils.InstrumentationLibrary()
+			spans := ils.Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				if span.IsNil() {
+					continue
+				}
+
+				if ok := v.visit(resource, instrumentationLibrary, span); !ok {
+					return
+				}
+			}
+		}
+	}
+}
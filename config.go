@@ -0,0 +1,48 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"time"
+
+	"go.opentelemetry.io/collector/config/configmodels"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+)
+
+// Config defines the configuration options for the Azure Monitor exporter.
+type Config struct {
+	configmodels.ExporterSettings `mapstructure:",squash"`
+
+	Endpoint           string `mapstructure:"endpoint"`
+	InstrumentationKey string `mapstructure:"instrumentation_key"`
+	// ConnectionString, when set, takes precedence over Endpoint and InstrumentationKey. It is the
+	// single connection string Azure Monitor customers copy from the portal, e.g.
+	// "InstrumentationKey=...;IngestionEndpoint=...;LiveEndpoint=...".
+	ConnectionString string `mapstructure:"connection_string"`
+
+	MaxBatchSize     int           `mapstructure:"maxbatchsize"`
+	MaxBatchInterval time.Duration `mapstructure:"maxbatchinterval"`
+	ShutdownTimeout  time.Duration `mapstructure:"shutdown_timeout"`
+
+	// QueueSettings, when Enabled, routes telemetry through a persistent, filestorage-backed
+	// queue instead of the in-memory App Insights channel, so buffered data survives restarts.
+	QueueSettings QueueSettings `mapstructure:"sending_queue"`
+	// RetrySettings governs backoff applied to transient send failures when QueueSettings is enabled.
+	RetrySettings exporterhelper.RetrySettings `mapstructure:"retry_on_failure"`
+
+	// SamplingPercentage is the percentage, 0-100, of traces to keep. All spans in a trace share
+	// the same keep/drop decision. Defaults to 100 (no sampling).
+	SamplingPercentage float64 `mapstructure:"sampling_percentage"`
+}
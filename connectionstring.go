@@ -0,0 +1,101 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// connectionVars holds the fields that can be extracted from an Application Insights
+// connection string. See https://docs.microsoft.com/azure/azure-monitor/app/sdk-connection-string
+type connectionVars struct {
+	InstrumentationKey string
+	IngestionEndpoint  string
+	LiveEndpoint       string
+	AADAudience        string
+}
+
+// parseConnectionString parses a semicolon-delimited Application Insights connection string
+// of the form "Key1=Value1;Key2=Value2" into its component fields.
+func parseConnectionString(connectionString string) (connectionVars, error) {
+	var vars connectionVars
+
+	for _, pair := range strings.Split(connectionString, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return connectionVars{}, fmt.Errorf("invalid connection string segment %q", pair)
+		}
+
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		value := strings.TrimSpace(kv[1])
+
+		switch key {
+		case "instrumentationkey":
+			vars.InstrumentationKey = value
+		case "ingestionendpoint":
+			vars.IngestionEndpoint = value
+		case "liveendpoint":
+			vars.LiveEndpoint = value
+		case "endpointsuffix":
+			// ignored: regional suffix resolution is not supported, IngestionEndpoint takes priority
+		case "aadaudience":
+			vars.AADAudience = value
+		}
+	}
+
+	if vars.InstrumentationKey == "" {
+		return connectionVars{}, fmt.Errorf("connection string is missing InstrumentationKey")
+	}
+
+	return vars, nil
+}
+
+// formatIngestionEndpoint turns the bare base URL from a connection string's IngestionEndpoint
+// (e.g. "https://eastus-8.in.applicationinsights.azure.com/") into the full path the AppInsights
+// SDK posts telemetry to, matching the shape of defaultEndpoint.
+func formatIngestionEndpoint(endpoint string) string {
+	return strings.TrimRight(endpoint, "/") + "/v2/track"
+}
+
+// applyConnectionString resolves cfg.InstrumentationKey and cfg.Endpoint from cfg.ConnectionString
+// when one is configured, overriding any statically configured values. It returns a config error
+// if neither a connection string nor an instrumentation key yields a usable instrumentation key.
+func applyConnectionString(cfg *Config) error {
+	if cfg.ConnectionString == "" {
+		if cfg.InstrumentationKey == "" {
+			return errConnectionRequired
+		}
+		return nil
+	}
+
+	connVars, err := parseConnectionString(cfg.ConnectionString)
+	if err != nil {
+		return fmt.Errorf("invalid connection_string: %w", err)
+	}
+
+	cfg.InstrumentationKey = connVars.InstrumentationKey
+	if connVars.IngestionEndpoint != "" {
+		cfg.Endpoint = formatIngestionEndpoint(connVars.IngestionEndpoint)
+	} else {
+		cfg.Endpoint = defaultEndpoint
+	}
+	return nil
+}
@@ -34,6 +34,7 @@ const (
 
 var (
 	errUnexpectedConfigurationType = errors.New("failed to cast configuration to Azure Monitor Config")
+	errConnectionRequired          = errors.New("either connection_string or instrumentation_key must be configured")
 )
 
 // NewFactory returns a factory for Azure Monitor exporter.
@@ -42,7 +43,8 @@ func NewFactory() component.ExporterFactory {
 	return exporterhelper.NewFactory(
 		typeStr,
 		createDefaultConfig,
-		exporterhelper.WithTraces(f.createTraceExporter))
+		exporterhelper.WithTraces(f.createTraceExporter),
+		exporterhelper.WithMetrics(f.createMetricsExporter))
 }
 
 // Implements the interface from go.opentelemetry.io/collector/exporter/factory.go
@@ -60,6 +62,11 @@ func createDefaultConfig() configmodels.Exporter {
 		MaxBatchSize:     1024,
 		MaxBatchInterval: 10 * time.Second,
 		ShutdownTimeout:  5 * time.Second,
+		QueueSettings: QueueSettings{
+			QueueSettings: exporterhelper.CreateDefaultQueueSettings(),
+		},
+		RetrySettings:      exporterhelper.CreateDefaultRetrySettings(),
+		SamplingPercentage: 100,
 	}
 }
 
@@ -74,10 +81,33 @@ func (f *factory) createTraceExporter(
 		return nil, errUnexpectedConfigurationType
 	}
 
+	if err := applyConnectionString(exporterConfig); err != nil {
+		return nil, err
+	}
+
 	tc := f.getTransportChannel(exporterConfig, params.Logger)
 	return newTraceExporter(exporterConfig, tc, params.Logger)
 }
 
+func (f *factory) createMetricsExporter(
+	ctx context.Context,
+	params component.ExporterCreateParams,
+	cfg configmodels.Exporter,
+) (component.MetricsExporter, error) {
+	exporterConfig, ok := cfg.(*Config)
+
+	if !ok {
+		return nil, errUnexpectedConfigurationType
+	}
+
+	if err := applyConnectionString(exporterConfig); err != nil {
+		return nil, err
+	}
+
+	tc := f.getTransportChannel(exporterConfig, params.Logger)
+	return newMetricsExporter(exporterConfig, tc, params.Logger)
+}
+
 // Configures the transport channel.
 // This method is not thread-safe
 func (f *factory) getTransportChannel(exporterConfig *Config, logger *zap.Logger) transportChannel {
@@ -85,6 +115,8 @@ func (f *factory) getTransportChannel(exporterConfig *Config, logger *zap.Logger
 	// The default transport channel uses the default send mechanism from the AppInsights telemetry client.
 	// This default channel handles batching, appropriate retries, and is backed by memory.
 	if f.tChannel == nil {
+		// exporterConfig.InstrumentationKey/Endpoint have already been resolved from
+		// ConnectionString, if one was configured, by applyConnectionString.
 		telemetryConfiguration := appinsights.NewTelemetryConfiguration(exporterConfig.InstrumentationKey)
 		telemetryConfiguration.EndpointUrl = exporterConfig.Endpoint
 		telemetryConfiguration.MaxBatchSize = exporterConfig.MaxBatchSize
@@ -100,6 +132,21 @@ func (f *factory) getTransportChannel(exporterConfig *Config, logger *zap.Logger
 				return nil
 			})
 		}
+
+		if exporterConfig.QueueSettings.Enabled {
+			storage, err := newFileStorageClient(exporterConfig.QueueSettings.StorageID)
+			if err != nil {
+				logger.Error("Failed to initialize persistent queue storage, falling back to the in-memory channel", zap.Error(err))
+			} else {
+				f.tChannel = newPersistentTransportChannel(
+					f.tChannel,
+					storage,
+					exporterConfig.QueueSettings,
+					exporterConfig.RetrySettings,
+					exporterConfig.MaxBatchInterval,
+					logger)
+			}
+		}
 	}
 
 	return f.tChannel
@@ -0,0 +1,60 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// fileStorageClient is a minimal storageClient backed by one file per key underneath a directory
+// named after the configured storage extension ID. It exists so the persistent queue has
+// somewhere durable to write when a full filestorage extension is not wired up.
+type fileStorageClient struct {
+	dir string
+}
+
+func newFileStorageClient(storageID string) (*fileStorageClient, error) {
+	dir := filepath.Join(os.TempDir(), "otelcol-azuremonitor", storageID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &fileStorageClient{dir: dir}, nil
+}
+
+func (c *fileStorageClient) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (c *fileStorageClient) Set(ctx context.Context, key string, value []byte) error {
+	return os.WriteFile(filepath.Join(c.dir, key), value, 0o600)
+}
+
+func (c *fileStorageClient) Delete(ctx context.Context, key string) error {
+	err := os.Remove(filepath.Join(c.dir, key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *fileStorageClient) Close(ctx context.Context) error {
+	return nil
+}
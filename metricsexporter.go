@@ -0,0 +1,95 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.uber.org/zap"
+)
+
+type metricsExporter struct {
+	config           *Config
+	transportChannel transportChannel
+	logger           *zap.Logger
+	shutdownTimeout  time.Duration
+}
+
+type metricsVisitor struct {
+	processed int
+	err       error
+	exporter  *metricsExporter
+}
+
+// Called for each tuple of Resource, InstrumentationLibrary, Metric, and data point index.
+func (v *metricsVisitor) visit(
+	resource pdata.Resource,
+	instrumentationLibrary pdata.InstrumentationLibrary,
+	metric pdata.Metric,
+	dataPointIndex int) (ok bool) {
+
+	envelopes, err := metricToEnvelopes(resource, instrumentationLibrary, metric, dataPointIndex)
+	if err != nil {
+		// record the error and short-circuit
+		v.err = consumererror.Permanent(err)
+		return false
+	}
+
+	for _, envelope := range envelopes {
+		envelope.IKey = v.exporter.config.InstrumentationKey
+		// This is a fire and forget operation
+		v.exporter.transportChannel.Send(envelope)
+	}
+	v.processed++
+
+	return true
+}
+
+func (exporter *metricsExporter) onMetricsData(context context.Context, metricsData pdata.Metrics) (droppedTimeSeries int, err error) {
+	_, dataPointCount := metricsData.MetricAndDataPointCount()
+	exporter.logger.Debug("Exporting metrics", zap.Int("Count", dataPointCount))
+	if dataPointCount == 0 {
+		return 0, nil
+	}
+
+	visitor := &metricsVisitor{exporter: exporter}
+	acceptMetrics(metricsData, visitor)
+	exporter.logger.Debug("Exported metrics", zap.Int("Dropped", dataPointCount-visitor.processed), zap.Error(visitor.err))
+	return (dataPointCount - visitor.processed), visitor.err
+}
+
+func (exporter *metricsExporter) Shutdown(context.Context) error {
+	exporter.logger.Info("Shutting down metrics exporter", zap.String("Shutdown Timeout", exporter.shutdownTimeout.String()))
+	<-exporter.transportChannel.Close(exporter.shutdownTimeout)
+	return nil
+}
+
+// Returns a new instance of the metrics exporter
+func newMetricsExporter(config *Config, transportChannel transportChannel, logger *zap.Logger) (component.MetricsExporter, error) {
+
+	exporter := &metricsExporter{
+		config:           config,
+		transportChannel: transportChannel,
+		logger:           logger,
+		shutdownTimeout:  config.ShutdownTimeout,
+	}
+
+	return exporterhelper.NewMetricsExporter(config, logger, exporter.onMetricsData, exporterhelper.WithShutdown(exporter.Shutdown))
+}
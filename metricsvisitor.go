@@ -0,0 +1,241 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// metricDataPointVisitor is called for each tuple of Resource, InstrumentationLibrary, Metric, and DataPoint.
+// Returning false stops the traversal.
+type metricDataPointVisitor interface {
+	visit(resource pdata.Resource, instrumentationLibrary pdata.InstrumentationLibrary, metric pdata.Metric, dataPointIndex int) (ok bool)
+}
+
+// acceptMetrics walks metricsData calling v.visit for every data point of every metric,
+// stopping early if visit returns false.
+func acceptMetrics(metricsData pdata.Metrics, v metricDataPointVisitor) {
+	resourceMetrics := metricsData.ResourceMetrics()
+	for i := 0; i < resourceMetrics.Len(); i++ {
+		rm := resourceMetrics.At(i)
+		if rm.IsNil() {
+			continue
+		}
+
+		resource := rm.Resource()
+		ilms := rm.InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			ilm := ilms.At(j)
+			if ilm.IsNil() {
+				continue
+			}
+
+			instrumentationLibrary := ilm.InstrumentationLibrary()
+			metrics := ilm.Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+				if metric.IsNil() {
+					continue
+				}
+
+				if !visitDataPoints(resource, instrumentationLibrary, metric, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func visitDataPoints(resource pdata.Resource, instrumentationLibrary pdata.InstrumentationLibrary, metric pdata.Metric, v metricDataPointVisitor) bool {
+	count := dataPointCount(metric)
+	for idx := 0; idx < count; idx++ {
+		if ok := v.visit(resource, instrumentationLibrary, metric, idx); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func dataPointCount(metric pdata.Metric) int {
+	switch {
+	case !metric.IntGauge().IsNil():
+		return metric.IntGauge().DataPoints().Len()
+	case !metric.DoubleGauge().IsNil():
+		return metric.DoubleGauge().DataPoints().Len()
+	case !metric.IntSum().IsNil():
+		return metric.IntSum().DataPoints().Len()
+	case !metric.DoubleSum().IsNil():
+		return metric.DoubleSum().DataPoints().Len()
+	case !metric.DoubleHistogram().IsNil():
+		return metric.DoubleHistogram().DataPoints().Len()
+	case !metric.DoubleSummary().IsNil():
+		return metric.DoubleSummary().DataPoints().Len()
+	default:
+		return 0
+	}
+}
+
+// metricToEnvelopes converts the dataPointIndex'th data point of metric, along with its parent
+// resource and instrumentation library, into one or more Application Insights MetricData envelopes.
+// Histograms produce one envelope for the aggregate (count/sum) and one additional envelope per
+// non-empty bucket; min and max are not set. Summaries produce one aggregate envelope plus one
+// envelope per reported quantile.
+func metricToEnvelopes(
+	resource pdata.Resource,
+	instrumentationLibrary pdata.InstrumentationLibrary,
+	metric pdata.Metric,
+	dataPointIndex int,
+) ([]*appinsights.Envelope, error) {
+	switch {
+	case !metric.IntGauge().IsNil():
+		dp := metric.IntGauge().DataPoints().At(dataPointIndex)
+		return []*appinsights.Envelope{
+			newMetricEnvelope(resource, instrumentationLibrary, metric.Name(), float64(dp.Value()), 1, dp.Timestamp(), dp.LabelsMap()),
+		}, nil
+	case !metric.DoubleGauge().IsNil():
+		dp := metric.DoubleGauge().DataPoints().At(dataPointIndex)
+		return []*appinsights.Envelope{
+			newMetricEnvelope(resource, instrumentationLibrary, metric.Name(), dp.Value(), 1, dp.Timestamp(), dp.LabelsMap()),
+		}, nil
+	case !metric.IntSum().IsNil():
+		dp := metric.IntSum().DataPoints().At(dataPointIndex)
+		return []*appinsights.Envelope{
+			newMetricEnvelope(resource, instrumentationLibrary, metric.Name(), float64(dp.Value()), 1, dp.Timestamp(), dp.LabelsMap()),
+		}, nil
+	case !metric.DoubleSum().IsNil():
+		dp := metric.DoubleSum().DataPoints().At(dataPointIndex)
+		return []*appinsights.Envelope{
+			newMetricEnvelope(resource, instrumentationLibrary, metric.Name(), dp.Value(), 1, dp.Timestamp(), dp.LabelsMap()),
+		}, nil
+	case !metric.DoubleHistogram().IsNil():
+		dp := metric.DoubleHistogram().DataPoints().At(dataPointIndex)
+		return histogramToEnvelopes(resource, instrumentationLibrary, metric.Name(), dp), nil
+	case !metric.DoubleSummary().IsNil():
+		dp := metric.DoubleSummary().DataPoints().At(dataPointIndex)
+		return summaryToEnvelopes(resource, instrumentationLibrary, metric.Name(), dp), nil
+	default:
+		return nil, nil
+	}
+}
+
+// histogramToEnvelopes emits one envelope for the aggregate (count/sum) and, for every non-empty
+// bucket, one additional envelope whose value is the bucket's population, labeled with its upper
+// bound ("le"); the implicit overflow bucket is labeled "+Inf". The OTel histogram data point
+// carries no min/max, so the aggregate point leaves those fields unset rather than faking them.
+func histogramToEnvelopes(
+	resource pdata.Resource,
+	instrumentationLibrary pdata.InstrumentationLibrary,
+	name string,
+	dp pdata.DoubleHistogramDataPoint,
+) []*appinsights.Envelope {
+	aggregate := newMetricEnvelope(resource, instrumentationLibrary, name, dp.Sum(), uint32(dp.Count()), dp.Timestamp(), dp.LabelsMap())
+	metricData := aggregate.Data.(*contracts.Data).BaseData.(*contracts.MetricData)
+	metricData.Metrics[0].Kind = contracts.Aggregation
+
+	envelopes := []*appinsights.Envelope{aggregate}
+
+	bounds := dp.ExplicitBounds()
+	counts := dp.BucketCounts()
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+
+		le := "+Inf"
+		if i < len(bounds) {
+			le = strconv.FormatFloat(bounds[i], 'f', -1, 64)
+		}
+
+		bucketName := name + ".bucket"
+		envelope := newMetricEnvelope(resource, instrumentationLibrary, bucketName, float64(count), uint32(count), dp.Timestamp(), dp.LabelsMap())
+		bucketData := envelope.Data.(*contracts.Data).BaseData.(*contracts.MetricData)
+		bucketData.Properties["le"] = le
+		envelopes = append(envelopes, envelope)
+	}
+
+	return envelopes
+}
+
+// summaryToEnvelopes emits one envelope for the aggregate (count/sum) and one additional envelope
+// per reported quantile, labeled with its percentile, so pre-computed quantiles are not dropped.
+func summaryToEnvelopes(
+	resource pdata.Resource,
+	instrumentationLibrary pdata.InstrumentationLibrary,
+	name string,
+	dp pdata.DoubleSummaryDataPoint,
+) []*appinsights.Envelope {
+	aggregate := newMetricEnvelope(resource, instrumentationLibrary, name, dp.Sum(), uint32(dp.Count()), dp.Timestamp(), dp.LabelsMap())
+	metricData := aggregate.Data.(*contracts.Data).BaseData.(*contracts.MetricData)
+	metricData.Metrics[0].Kind = contracts.Aggregation
+
+	envelopes := []*appinsights.Envelope{aggregate}
+
+	quantiles := dp.ValueAtPercentiles()
+	for i := 0; i < quantiles.Len(); i++ {
+		q := quantiles.At(i)
+		quantileName := name + ".quantile"
+		envelope := newMetricEnvelope(resource, instrumentationLibrary, quantileName, q.Value(), 1, dp.Timestamp(), dp.LabelsMap())
+		quantileData := envelope.Data.(*contracts.Data).BaseData.(*contracts.MetricData)
+		quantileData.Properties["quantile"] = strconv.FormatFloat(q.Percentile()/100, 'f', -1, 64)
+		envelopes = append(envelopes, envelope)
+	}
+
+	return envelopes
+}
+
+func newMetricEnvelope(
+	resource pdata.Resource,
+	instrumentationLibrary pdata.InstrumentationLibrary,
+	name string,
+	value float64,
+	count uint32,
+	timestamp pdata.TimestampUnixNano,
+	labels pdata.StringMap,
+) *appinsights.Envelope {
+	envelope := appinsights.NewEnvelope()
+	envelope.Tags = make(contracts.ContextTags)
+	envelope.Time = toTime(timestamp).Format(time.RFC3339Nano)
+
+	metric := contracts.NewMetricData()
+	dataPoint := contracts.NewDataPoint()
+	dataPoint.Name = name
+	dataPoint.Value = value
+	dataPoint.Count = int(count)
+	dataPoint.Kind = contracts.Measurement
+	metric.Metrics = []*contracts.DataPoint{dataPoint}
+	metric.Properties = make(map[string]string)
+
+	labels.ForEach(func(k, v string) {
+		metric.Properties[k] = v
+	})
+
+	if instrumentationLibrary.Name() != "" {
+		metric.Properties["instrumentation_library_name"] = instrumentationLibrary.Name()
+	}
+
+	applyResourceTags(resource, envelope)
+
+	data := contracts.NewData()
+	data.BaseType = "MetricData"
+	data.BaseData = metric
+	envelope.Data = data
+
+	return envelope
+}
@@ -0,0 +1,103 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+func newTestResource() pdata.Resource {
+	resource := pdata.NewResource()
+	resource.InitEmpty()
+	return resource
+}
+
+func newTestInstrumentationLibrary() pdata.InstrumentationLibrary {
+	il := pdata.NewInstrumentationLibrary()
+	il.InitEmpty()
+	return il
+}
+
+func asMetricData(t *testing.T, data interface{}) *contracts.MetricData {
+	t.Helper()
+	d, ok := data.(*contracts.Data)
+	require.True(t, ok)
+	metricData, ok := d.BaseData.(*contracts.MetricData)
+	require.True(t, ok)
+	return metricData
+}
+
+func TestHistogramToEnvelopes_AggregateAndBuckets(t *testing.T) {
+	dp := pdata.NewDoubleHistogramDataPoint()
+	dp.InitEmpty()
+	dp.SetSum(30)
+	dp.SetCount(5)
+	dp.SetExplicitBounds([]float64{1, 5})
+	dp.SetBucketCounts([]uint64{2, 0, 3})
+
+	envelopes := histogramToEnvelopes(newTestResource(), newTestInstrumentationLibrary(), "latency", dp)
+
+	// One aggregate envelope, plus one per non-empty bucket (the middle, empty, bucket is skipped).
+	require.Len(t, envelopes, 3)
+
+	aggregate := asMetricData(t, envelopes[0].Data)
+	assert.Equal(t, "latency", aggregate.Metrics[0].Name)
+	assert.Equal(t, 30.0, aggregate.Metrics[0].Value)
+	assert.Equal(t, 5, aggregate.Metrics[0].Count)
+
+	firstBucket := asMetricData(t, envelopes[1].Data)
+	assert.Equal(t, "latency.bucket", firstBucket.Metrics[0].Name)
+	assert.Equal(t, 2.0, firstBucket.Metrics[0].Value, "bucket value should be its population, not its bound")
+	assert.Equal(t, "1", firstBucket.Properties["le"])
+
+	overflowBucket := asMetricData(t, envelopes[2].Data)
+	assert.Equal(t, 3.0, overflowBucket.Metrics[0].Value)
+	assert.Equal(t, "+Inf", overflowBucket.Properties["le"], "the implicit overflow bucket must be labeled +Inf, not 0")
+}
+
+func TestSummaryToEnvelopes_AggregateAndQuantiles(t *testing.T) {
+	dp := pdata.NewDoubleSummaryDataPoint()
+	dp.InitEmpty()
+	dp.SetSum(100)
+	dp.SetCount(10)
+	dp.ValueAtPercentiles().Resize(2)
+	dp.ValueAtPercentiles().At(0).SetPercentile(50)
+	dp.ValueAtPercentiles().At(0).SetValue(9)
+	dp.ValueAtPercentiles().At(1).SetPercentile(99)
+	dp.ValueAtPercentiles().At(1).SetValue(42)
+
+	envelopes := summaryToEnvelopes(newTestResource(), newTestInstrumentationLibrary(), "request.duration", dp)
+
+	require.Len(t, envelopes, 3)
+
+	aggregate := asMetricData(t, envelopes[0].Data)
+	assert.Equal(t, 100.0, aggregate.Metrics[0].Value)
+	assert.Equal(t, 10, aggregate.Metrics[0].Count)
+	assert.Equal(t, 0.0, aggregate.Metrics[0].Min)
+	assert.Equal(t, 0.0, aggregate.Metrics[0].Max, "min/max are not available on a DoubleSummaryDataPoint and must not be fabricated from Sum")
+
+	p50 := asMetricData(t, envelopes[1].Data)
+	assert.Equal(t, 9.0, p50.Metrics[0].Value)
+	assert.Equal(t, "0.5", p50.Properties["quantile"])
+
+	p99 := asMetricData(t, envelopes[2].Data)
+	assert.Equal(t, 42.0, p99.Metrics[0].Value)
+	assert.Equal(t, "0.99", p99.Properties["quantile"])
+}
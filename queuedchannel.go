@@ -0,0 +1,376 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.uber.org/zap"
+)
+
+// QueueSettings configures the optional persistent, disk-backed queue that can sit in front of
+// the Application Insights transport channel so that a collector restart, or a prolonged
+// ingestion outage, does not drop buffered telemetry.
+type QueueSettings struct {
+	exporterhelper.QueueSettings `mapstructure:",squash"`
+
+	// StorageID names the directory, under os.TempDir()/otelcol-azuremonitor, that the persistent
+	// queue writes its files to, e.g. "file_storage/azuremonitor". It is not resolved against a
+	// filestorage extension, and an empty value is accepted (files then go directly under
+	// otelcol-azuremonitor).
+	StorageID string `mapstructure:"storage"`
+}
+
+// storageClient is the subset of the filestorage extension's client used to persist queued
+// envelopes, and the index describing them, across restarts.
+type storageClient interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
+	Close(ctx context.Context) error
+}
+
+// queueIndexKey holds the list of outstanding envelope keys plus the next ID to hand out, so a
+// restarted collector knows what it still owes App Insights and doesn't reuse an in-flight key.
+const queueIndexKey = "azuremonitor_queue_index"
+
+type queueIndex struct {
+	NextID uint64   `json:"next_id"`
+	Keys   []string `json:"keys"`
+}
+
+var errTransientSendFailure = errors.New("transient failure reported by App Insights diagnostics listener")
+
+// persistentTransportChannel is a transportChannel that durably queues envelopes ahead of an
+// inner transportChannel, retrying transient send failures with exponential backoff and
+// surviving collector restarts via a filestorage-backed queue.
+type persistentTransportChannel struct {
+	next          transportChannel
+	storage       storageClient
+	logger        *zap.Logger
+	retry         exporterhelper.RetrySettings
+	batchInterval time.Duration
+
+	items chan queuedItem
+
+	mu     sync.Mutex
+	nextID uint64
+	index  map[string]struct{}
+	closed bool
+
+	stopped   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	// sendMu serializes submissions to next so that, at any moment, at most one envelope is
+	// outstanding. That is what makes lastFailed an unambiguous verdict on item: with multiple
+	// submissions in flight, one failure would falsely indict (or falsely clear) every other
+	// consumer's concurrent, unrelated item.
+	sendMu sync.Mutex
+
+	failureMu  sync.Mutex
+	lastFailed time.Time
+}
+
+type queuedItem struct {
+	key      string
+	envelope *appinsights.Envelope
+}
+
+func newPersistentTransportChannel(
+	next transportChannel,
+	storage storageClient,
+	queueSettings QueueSettings,
+	retrySettings exporterhelper.RetrySettings,
+	batchInterval time.Duration,
+	logger *zap.Logger,
+) *persistentTransportChannel {
+	p := &persistentTransportChannel{
+		next:          next,
+		storage:       storage,
+		logger:        logger,
+		retry:         retrySettings,
+		batchInterval: batchInterval,
+		items:         make(chan queuedItem, queueSettings.QueueSize),
+		index:         make(map[string]struct{}),
+		stopped:       make(chan struct{}),
+	}
+
+	// The diagnostics listener is App Insights' only feedback channel for submission outcomes;
+	// without it, Send is pure fire-and-forget and there is nothing to key a retry decision on.
+	appinsights.NewDiagnosticsMessageListener(func(msg string) error {
+		if isTransientFailureMessage(msg) {
+			p.recordTransientFailure()
+		}
+		return nil
+	})
+
+	for i := 0; i < queueSettings.NumConsumers; i++ {
+		p.wg.Add(1)
+		go p.consume()
+	}
+
+	// Consumers must already be running before this replays onto p.items: a restart that finds
+	// more envelopes on disk than QueueSize would otherwise block this send forever and the
+	// exporter would never finish starting up.
+	p.reloadFromStorage()
+
+	return p
+}
+
+// reloadFromStorage restores the queue index left behind by a previous process and re-enqueues
+// any envelopes that were persisted but never confirmed delivered, so a collector restart does
+// not drop buffered telemetry.
+func (p *persistentTransportChannel) reloadFromStorage() {
+	payload, err := p.storage.Get(context.Background(), queueIndexKey)
+	if err != nil {
+		p.logger.Warn("Failed to read persistent queue index, starting with an empty queue", zap.Error(err))
+		return
+	}
+	if payload == nil {
+		return
+	}
+
+	var idx queueIndex
+	if err := json.Unmarshal(payload, &idx); err != nil {
+		p.logger.Warn("Failed to parse persistent queue index, starting with an empty queue", zap.Error(err))
+		return
+	}
+
+	p.nextID = idx.NextID
+
+	for _, key := range idx.Keys {
+		envelopePayload, err := p.storage.Get(context.Background(), key)
+		if err != nil || envelopePayload == nil {
+			continue
+		}
+
+		var envelope appinsights.Envelope
+		if err := json.Unmarshal(envelopePayload, &envelope); err != nil {
+			p.logger.Warn("Dropping unreadable envelope left over from a previous run", zap.String("key", key), zap.Error(err))
+			continue
+		}
+
+		p.index[key] = struct{}{}
+		p.items <- queuedItem{key: key, envelope: &envelope}
+	}
+
+	if len(idx.Keys) > 0 {
+		p.logger.Info("Replayed envelopes from the persistent queue", zap.Int("Count", len(idx.Keys)))
+	}
+}
+
+// Send persists the envelope to storage and enqueues it for asynchronous delivery. Like the
+// underlying App Insights channel, this is a fire-and-forget operation.
+func (p *persistentTransportChannel) Send(envelope *appinsights.Envelope) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		p.logger.Warn("Dropping envelope submitted after the persistent queue was closed")
+		return
+	}
+
+	p.nextID++
+	key := "azuremonitor_" + strconv.FormatUint(p.nextID, 10)
+	p.index[key] = struct{}{}
+	idx := p.snapshotIndexLocked()
+	p.mu.Unlock()
+
+	if payload, err := json.Marshal(envelope); err == nil {
+		if err := p.storage.Set(context.Background(), key, payload); err != nil {
+			p.logger.Warn("Failed to persist envelope to storage, continuing without durability", zap.Error(err))
+		}
+	} else {
+		p.logger.Warn("Failed to marshal envelope for persistence", zap.Error(err))
+	}
+	p.persistIndex(idx)
+
+	p.items <- queuedItem{key: key, envelope: envelope}
+}
+
+func (p *persistentTransportChannel) snapshotIndexLocked() queueIndex {
+	keys := make([]string, 0, len(p.index))
+	for key := range p.index {
+		keys = append(keys, key)
+	}
+	return queueIndex{NextID: p.nextID, Keys: keys}
+}
+
+func (p *persistentTransportChannel) persistIndex(idx queueIndex) {
+	payload, err := json.Marshal(idx)
+	if err != nil {
+		p.logger.Warn("Failed to marshal persistent queue index", zap.Error(err))
+		return
+	}
+	if err := p.storage.Set(context.Background(), queueIndexKey, payload); err != nil {
+		p.logger.Warn("Failed to persist queue index", zap.Error(err))
+	}
+}
+
+func (p *persistentTransportChannel) consume() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case item := <-p.items:
+			p.sendWithRetry(item)
+			continue
+		default:
+		}
+
+		select {
+		case item := <-p.items:
+			p.sendWithRetry(item)
+		case <-p.stopped:
+			if len(p.items) == 0 {
+				return
+			}
+			// Items are still in flight; yield and take another pass before checking again.
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// sendWithRetry calls the inner channel's Send, retrying on transient failures reported by the
+// App Insights diagnostics listener with backoff bounded by RetrySettings. An envelope that never
+// succeeds is left in storage so it can be replayed on the next restart, rather than being lost.
+func (p *persistentTransportChannel) sendWithRetry(item queuedItem) {
+	attempt := func() error {
+		// The App Insights channel only reports failures through the diagnostics listener, as a
+		// free-floating message with no envelope identity attached. sendMu keeps at most one
+		// envelope outstanding at a time so that verdict can be attributed to this item alone;
+		// without it, concurrent consumers would see each other's failures and either delete a
+		// not-yet-delivered envelope or needlessly resend one that already succeeded.
+		p.sendMu.Lock()
+		defer p.sendMu.Unlock()
+
+		since := time.Now()
+		p.next.Send(item.envelope)
+
+		// Wait out the App Insights channel's batching window before trusting the diagnostics
+		// listener's silence as success; checking any sooner would race the actual transmission.
+		time.Sleep(p.flushWait())
+		if p.failedSince(since) {
+			return errTransientSendFailure
+		}
+		return nil
+	}
+
+	var err error
+	if p.retry.Enabled {
+		b := backoff.NewExponentialBackOff()
+		b.InitialInterval = p.retry.InitialInterval
+		b.MaxInterval = p.retry.MaxInterval
+		b.MaxElapsedTime = p.retry.MaxElapsedTime
+		err = backoff.Retry(attempt, b)
+	} else {
+		err = attempt()
+	}
+
+	if err != nil {
+		p.logger.Warn("Exhausted retries sending envelope; leaving it in storage for redelivery on restart",
+			zap.String("key", item.key), zap.Error(err))
+		return
+	}
+
+	p.mu.Lock()
+	delete(p.index, item.key)
+	idx := p.snapshotIndexLocked()
+	p.mu.Unlock()
+
+	p.persistIndex(idx)
+	if err := p.storage.Delete(context.Background(), item.key); err != nil {
+		p.logger.Warn("Failed to remove delivered envelope from storage", zap.String("key", item.key), zap.Error(err))
+	}
+}
+
+// flushWait is how long to wait for the App Insights channel to actually transmit a submission,
+// and the diagnostics listener to report the outcome, before trusting its silence as success.
+// The channel batches up to batchInterval, so waiting any less would routinely check before the
+// real attempt has even gone out.
+func (p *persistentTransportChannel) flushWait() time.Duration {
+	const minFlushWait = 100 * time.Millisecond
+	if p.batchInterval < minFlushWait {
+		return minFlushWait
+	}
+	return p.batchInterval
+}
+
+func (p *persistentTransportChannel) recordTransientFailure() {
+	p.failureMu.Lock()
+	p.lastFailed = time.Now()
+	p.failureMu.Unlock()
+}
+
+func (p *persistentTransportChannel) failedSince(since time.Time) bool {
+	p.failureMu.Lock()
+	defer p.failureMu.Unlock()
+	return p.lastFailed.After(since)
+}
+
+// isTransientFailureMessage recognizes the subset of App Insights diagnostics messages that
+// indicate a submission did not make it to the ingestion endpoint and should be retried.
+func isTransientFailureMessage(msg string) bool {
+	lower := strings.ToLower(msg)
+	for _, marker := range []string{"error", "fail", "retry", "timeout", "429", "503"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close stops accepting new envelopes and drains the persistent queue and the underlying channel,
+// both bounded by timeout. Envelopes still outstanding when timeout elapses remain in storage and
+// will be replayed the next time the exporter starts.
+func (p *persistentTransportChannel) Close(timeout time.Duration) <-chan struct{} {
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+		close(p.stopped)
+	})
+
+	result := make(chan struct{})
+
+	go func() {
+		drained := make(chan struct{})
+		go func() {
+			p.wg.Wait()
+			close(drained)
+		}()
+
+		select {
+		case <-drained:
+		case <-time.After(timeout):
+			p.logger.Warn("Timed out waiting for persistent queue to drain; undelivered envelopes remain in storage for redelivery after restart")
+		}
+
+		_ = p.storage.Close(context.Background())
+		<-p.next.Close(timeout)
+		close(result)
+	}()
+
+	return result
+}
@@ -0,0 +1,69 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+const msSampleRateTag = "_MS.sampleRate"
+
+// sampleSpan decides whether a span should be kept and, if so, the sample rate that should be
+// recorded on its envelope. All spans belonging to the same trace share the same decision,
+// since the keep/drop hash is computed over the TraceID rather than per-span randomness.
+//
+// An existing "_MS.sampleRate" entry in the incoming W3C tracestate, set by an upstream sampler,
+// takes precedence over configuredPercentage so the sample rate propagates end-to-end across a
+// chain of collectors.
+func sampleSpan(span pdata.Span, configuredPercentage float64) (keep bool, sampleRate float64) {
+	sampleRate = configuredPercentage
+	if upstreamRate, ok := tracestateSampleRate(span.TraceState()); ok {
+		sampleRate = upstreamRate
+	}
+
+	if sampleRate >= 100 {
+		return true, sampleRate
+	}
+	if sampleRate <= 0 {
+		return false, sampleRate
+	}
+
+	return traceIDBucket(span.TraceID()) < sampleRate, sampleRate
+}
+
+// traceIDBucket deterministically maps a TraceID onto [0, 100) using FNV-1a, so every span in the
+// same trace falls into the same bucket.
+func traceIDBucket(traceID pdata.TraceID) float64 {
+	h := fnv.New32a()
+	h.Write(traceID.Bytes())
+	return float64(h.Sum32() % 100)
+}
+
+func tracestateSampleRate(ts pdata.TraceState) (float64, bool) {
+	for _, entry := range strings.Split(string(ts), ",") {
+		kv := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(kv) != 2 || kv[0] != msSampleRateTag {
+			continue
+		}
+		if rate, err := strconv.ParseFloat(kv[1], 64); err == nil {
+			return rate, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+func traceIDFromByte(b byte) pdata.TraceID {
+	var id [16]byte
+	id[15] = b
+	return pdata.NewTraceID(id)
+}
+
+func newTestSpan(traceID pdata.TraceID, traceState pdata.TraceState) pdata.Span {
+	span := pdata.NewSpan()
+	span.InitEmpty()
+	span.SetTraceID(traceID)
+	span.SetTraceState(traceState)
+	return span
+}
+
+func TestSampleSpan_KeepAll(t *testing.T) {
+	span := newTestSpan(traceIDFromByte(1), pdata.TraceState(""))
+	keep, rate := sampleSpan(span, 100)
+	assert.True(t, keep)
+	assert.Equal(t, 100.0, rate)
+}
+
+func TestSampleSpan_DropAll(t *testing.T) {
+	span := newTestSpan(traceIDFromByte(1), pdata.TraceState(""))
+	keep, rate := sampleSpan(span, 0)
+	assert.False(t, keep)
+	assert.Equal(t, 0.0, rate)
+}
+
+func TestSampleSpan_DeterministicPerTrace(t *testing.T) {
+	traceID := traceIDFromByte(42)
+
+	keep1, rate1 := sampleSpan(newTestSpan(traceID, pdata.TraceState("")), 50)
+	keep2, rate2 := sampleSpan(newTestSpan(traceID, pdata.TraceState("")), 50)
+
+	assert.Equal(t, keep1, keep2)
+	assert.Equal(t, rate1, rate2)
+}
+
+func TestSampleSpan_TracestateOverridesConfiguredPercentage(t *testing.T) {
+	span := newTestSpan(traceIDFromByte(7), pdata.TraceState("_MS.sampleRate=25"))
+
+	_, rate := sampleSpan(span, 100)
+
+	assert.Equal(t, 25.0, rate)
+}
+
+func TestTracestateSampleRate_Missing(t *testing.T) {
+	_, ok := tracestateSampleRate(pdata.TraceState("vendor=value"))
+	assert.False(t, ok)
+}
+
+func TestTracestateSampleRate_Present(t *testing.T) {
+	rate, ok := tracestateSampleRate(pdata.TraceState("vendor=value, _MS.sampleRate=10"))
+	assert.True(t, ok)
+	assert.Equal(t, 10.0, rate)
+}
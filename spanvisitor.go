@@ -0,0 +1,313 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+)
+
+const (
+	attributeServiceName = "service.name"
+	attributeHostName    = "host.name"
+
+	attributeExceptionType       = "exception.type"
+	attributeExceptionMessage    = "exception.message"
+	attributeExceptionStacktrace = "exception.stacktrace"
+	attributeEventSeverity       = "event.severity"
+
+	eventNameException = "exception"
+
+	msLinksProperty = "_MS.links"
+)
+
+// spanToEnvelopes converts a span, along with its parent resource and instrumentation library,
+// into one or more Application Insights envelopes. The span itself becomes a RequestData envelope
+// (SpanKindServer, SpanKindConsumer) or a RemoteDependencyData envelope (every other kind); each of
+// the span's events becomes an additional TraceTelemetry or ExceptionTelemetry envelope.
+func spanToEnvelopes(
+	resource pdata.Resource,
+	instrumentationLibrary pdata.InstrumentationLibrary,
+	span pdata.Span,
+	sampleRate float64,
+	logger *zap.Logger,
+) ([]*appinsights.Envelope, error) {
+	properties := attributesToProperties(span.Attributes())
+	if instrumentationLibrary.Name() != "" {
+		properties["instrumentation_library_name"] = instrumentationLibrary.Name()
+	}
+	if links := linksToProperty(span.Links()); links != "" {
+		properties[msLinksProperty] = links
+	}
+
+	var data *contracts.Data
+	switch span.Kind() {
+	case pdata.SpanKindSERVER, pdata.SpanKindCONSUMER:
+		data = requestData(span, properties)
+	default:
+		data = remoteDependencyData(span, properties)
+	}
+
+	envelope := newSpanEnvelope(resource, span.TraceID(), span.ParentSpanID(), span.StartTime(), sampleRate)
+	envelope.Data = data
+
+	envelopes := []*appinsights.Envelope{envelope}
+
+	events := span.Events()
+	for i := 0; i < events.Len(); i++ {
+		event := events.At(i)
+		if event.IsNil() {
+			continue
+		}
+		envelopes = append(envelopes, eventToEnvelope(resource, span, event, sampleRate))
+	}
+
+	return envelopes, nil
+}
+
+// newSpanEnvelope builds an envelope with the operation tags shared by a span and the
+// telemetry items (events) that hang off of it, and records the sample rate Azure Monitor
+// should use to scale aggregates for this envelope back up.
+func newSpanEnvelope(resource pdata.Resource, traceID pdata.TraceID, parentSpanID pdata.SpanID, timestamp pdata.TimestampUnixNano, sampleRate float64) *appinsights.Envelope {
+	envelope := appinsights.NewEnvelope()
+	envelope.Tags = make(contracts.ContextTags)
+	envelope.Time = toTime(timestamp).Format(time.RFC3339Nano)
+	envelope.SampleRate = sampleRate
+	envelope.Tags[msSampleRateTag] = strconv.FormatFloat(sampleRate, 'f', -1, 64)
+
+	envelope.Tags[contracts.OperationId] = traceID.HexString()
+	if parentSpanID.IsValid() {
+		envelope.Tags[contracts.OperationParentId] = parentSpanID.HexString()
+	}
+
+	applyResourceTags(resource, envelope)
+	return envelope
+}
+
+// eventToEnvelope converts a span event into an ExceptionTelemetry envelope, when the event
+// follows the OTel "exception" semantic convention, or a TraceTelemetry envelope otherwise.
+func eventToEnvelope(resource pdata.Resource, span pdata.Span, event pdata.SpanEvent, sampleRate float64) *appinsights.Envelope {
+	envelope := newSpanEnvelope(resource, span.TraceID(), span.SpanID(), event.Timestamp(), sampleRate)
+
+	attrs := event.Attributes()
+	properties := attributesToProperties(attrs)
+
+	var data *contracts.Data
+	if event.Name() == eventNameException {
+		exceptionData := contracts.NewExceptionData()
+		exceptionData.Properties = properties
+
+		detail := contracts.NewExceptionDetails()
+		if v, ok := attrs.Get(attributeExceptionType); ok {
+			detail.TypeName = v.StringVal()
+		}
+		if v, ok := attrs.Get(attributeExceptionMessage); ok {
+			detail.Message = v.StringVal()
+		}
+		if v, ok := attrs.Get(attributeExceptionStacktrace); ok {
+			detail.HasFullStack = true
+			detail.Stack = v.StringVal()
+		}
+		exceptionData.Exceptions = []*contracts.ExceptionDetails{detail}
+
+		data = contracts.NewData()
+		data.BaseType = "ExceptionData"
+		data.BaseData = exceptionData
+	} else {
+		messageData := contracts.NewMessageData()
+		messageData.Message = event.Name()
+		messageData.Properties = properties
+		messageData.SeverityLevel = eventSeverityLevel(attrs)
+
+		data = contracts.NewData()
+		data.BaseType = "MessageData"
+		data.BaseData = messageData
+	}
+
+	envelope.Data = data
+	return envelope
+}
+
+// eventSeverityLevel maps the event.severity attribute, if present, onto an App Insights
+// SeverityLevel, defaulting to Information when absent or unrecognized.
+func eventSeverityLevel(attrs pdata.AttributeMap) contracts.SeverityLevel {
+	v, ok := attrs.Get(attributeEventSeverity)
+	if !ok {
+		return contracts.Information
+	}
+
+	switch strings.ToUpper(v.StringVal()) {
+	case "TRACE", "DEBUG":
+		return contracts.Verbose
+	case "WARN", "WARNING":
+		return contracts.Warning
+	case "ERROR":
+		return contracts.Error
+	case "FATAL", "CRITICAL":
+		return contracts.Critical
+	default:
+		return contracts.Information
+	}
+}
+
+// linksToProperty renders a span's links as the "_MS.links" JSON array Azure Monitor's
+// Application Map uses to wire up cross-component relationships.
+func linksToProperty(links pdata.SpanLinkSlice) string {
+	if links.Len() == 0 {
+		return ""
+	}
+
+	type msLink struct {
+		OperationID string `json:"operation_Id"`
+		ID          string `json:"id"`
+	}
+
+	rendered := make([]msLink, 0, links.Len())
+	for i := 0; i < links.Len(); i++ {
+		link := links.At(i)
+		if link.IsNil() {
+			continue
+		}
+		rendered = append(rendered, msLink{
+			OperationID: link.TraceID().HexString(),
+			ID:          link.SpanID().HexString(),
+		})
+	}
+
+	payload, err := json.Marshal(rendered)
+	if err != nil {
+		return ""
+	}
+	return string(payload)
+}
+
+func requestData(span pdata.Span, properties map[string]string) *contracts.Data {
+	request := contracts.NewRequestData()
+	request.Id = span.SpanID().HexString()
+	request.Name = span.Name()
+	request.Duration = formatDuration(span.EndTime() - span.StartTime())
+	request.ResponseCode = strconv.Itoa(int(span.Status().Code()))
+	request.Success = span.Status().Code() == pdata.StatusCode(0)
+	request.Properties = properties
+
+	data := contracts.NewData()
+	data.BaseType = "RequestData"
+	data.BaseData = request
+	return data
+}
+
+func remoteDependencyData(span pdata.Span, properties map[string]string) *contracts.Data {
+	dependency := contracts.NewRemoteDependencyData()
+	dependency.Id = span.SpanID().HexString()
+	dependency.Name = span.Name()
+	dependency.Duration = formatDuration(span.EndTime() - span.StartTime())
+	dependency.ResultCode = strconv.Itoa(int(span.Status().Code()))
+	dependency.Success = span.Status().Code() == pdata.StatusCode(0)
+	dependency.Properties = properties
+
+	data := contracts.NewData()
+	data.BaseType = "RemoteDependencyData"
+	data.BaseData = dependency
+	return data
+}
+
+// applyResourceTags maps well known OTel semantic convention resource attributes onto envelope tags.
+func applyResourceTags(resource pdata.Resource, envelope *appinsights.Envelope) {
+	if resource.IsNil() {
+		return
+	}
+
+	attrs := resource.Attributes()
+	if v, ok := attrs.Get(attributeServiceName); ok {
+		envelope.Tags[contracts.CloudRole] = v.StringVal()
+	}
+	if v, ok := attrs.Get(attributeHostName); ok {
+		envelope.Tags[contracts.DeviceId] = v.StringVal()
+	}
+}
+
+func attributesToProperties(attributeMap pdata.AttributeMap) map[string]string {
+	properties := make(map[string]string)
+	attributeMap.ForEach(func(k string, v pdata.AttributeValue) {
+		properties[k] = attributeValueToString(v)
+	})
+	return properties
+}
+
+func attributeValueToString(v pdata.AttributeValue) string {
+	switch v.Type() {
+	case pdata.AttributeValueSTRING:
+		return v.StringVal()
+	case pdata.AttributeValueBOOL:
+		return strconv.FormatBool(v.BoolVal())
+	case pdata.AttributeValueINT:
+		return strconv.FormatInt(v.IntVal(), 10)
+	case pdata.AttributeValueDOUBLE:
+		return strconv.FormatFloat(v.DoubleVal(), 'f', -1, 64)
+	default:
+		return v.StringVal()
+	}
+}
+
+// timespanTicksPerSecond is the number of 100-nanosecond ticks in a second, matching the
+// resolution of .NET's TimeSpan, which is what AppInsights RequestData/RemoteDependencyData
+// Duration fields are parsed as ("d.hh:mm:ss.fffffff").
+const timespanTicksPerSecond = int64(time.Second / 100)
+
+// formatDuration renders a span duration in the AppInsights timespan format, e.g. "00:00:01.5000000"
+// or, for multi-day spans, "1.00:00:00.0000000".
+func formatDuration(d pdata.TimestampUnixNano) string {
+	ticks := int64(d) / 100
+
+	sign := ""
+	if ticks < 0 {
+		sign = "-"
+		ticks = -ticks
+	}
+
+	ticksPerDay := timespanTicksPerSecond * 60 * 60 * 24
+	days := ticks / ticksPerDay
+	ticks %= ticksPerDay
+
+	ticksPerHour := timespanTicksPerSecond * 60 * 60
+	hours := ticks / ticksPerHour
+	ticks %= ticksPerHour
+
+	ticksPerMinute := timespanTicksPerSecond * 60
+	minutes := ticks / ticksPerMinute
+	ticks %= ticksPerMinute
+
+	seconds := ticks / timespanTicksPerSecond
+	fraction := ticks % timespanTicksPerSecond
+
+	dayPrefix := ""
+	if days > 0 {
+		dayPrefix = fmt.Sprintf("%d.", days)
+	}
+
+	return fmt.Sprintf("%s%s%02d:%02d:%02d.%07d", sign, dayPrefix, hours, minutes, seconds, fraction)
+}
+
+func toTime(ts pdata.TimestampUnixNano) time.Time {
+	return time.Unix(0, int64(ts)).UTC()
+}
@@ -0,0 +1,138 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"testing"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+)
+
+func newTestSpanWithEvents(t *testing.T, eventCount int) pdata.Span {
+	t.Helper()
+	span := pdata.NewSpan()
+	span.InitEmpty()
+	span.SetTraceID(traceIDFromByte(9))
+	span.SetSpanID(pdata.NewSpanID([8]byte{1}))
+	span.SetKind(pdata.SpanKindCLIENT)
+	span.Events().Resize(eventCount)
+	return span
+}
+
+func TestEventToEnvelope_Exception(t *testing.T) {
+	span := newTestSpanWithEvents(t, 1)
+	event := span.Events().At(0)
+	event.SetName("exception")
+	event.Attributes().InsertString(attributeExceptionType, "java.lang.RuntimeException")
+	event.Attributes().InsertString(attributeExceptionMessage, "boom")
+	event.Attributes().InsertString(attributeExceptionStacktrace, "at foo.bar")
+
+	envelope := eventToEnvelope(newTestResource(), span, event, 100)
+
+	data, ok := envelope.Data.(*contracts.Data)
+	require.True(t, ok)
+	assert.Equal(t, "ExceptionData", data.BaseType)
+
+	exceptionData, ok := data.BaseData.(*contracts.ExceptionData)
+	require.True(t, ok)
+	require.Len(t, exceptionData.Exceptions, 1)
+	assert.Equal(t, "java.lang.RuntimeException", exceptionData.Exceptions[0].TypeName)
+	assert.Equal(t, "boom", exceptionData.Exceptions[0].Message)
+	assert.Equal(t, "at foo.bar", exceptionData.Exceptions[0].Stack)
+
+	assert.Equal(t, span.TraceID().HexString(), envelope.Tags[contracts.OperationId])
+	assert.Equal(t, span.SpanID().HexString(), envelope.Tags[contracts.OperationParentId])
+}
+
+func TestEventToEnvelope_Message(t *testing.T) {
+	span := newTestSpanWithEvents(t, 1)
+	event := span.Events().At(0)
+	event.SetName("cache miss")
+	event.Attributes().InsertString(attributeEventSeverity, "warn")
+
+	envelope := eventToEnvelope(newTestResource(), span, event, 100)
+
+	data, ok := envelope.Data.(*contracts.Data)
+	require.True(t, ok)
+	assert.Equal(t, "MessageData", data.BaseType)
+
+	messageData, ok := data.BaseData.(*contracts.MessageData)
+	require.True(t, ok)
+	assert.Equal(t, "cache miss", messageData.Message)
+	assert.Equal(t, contracts.Warning, messageData.SeverityLevel)
+}
+
+func TestSpanToEnvelopes_OneEnvelopePerEvent(t *testing.T) {
+	span := newTestSpanWithEvents(t, 2)
+	span.Events().At(0).SetName("exception")
+	span.Events().At(1).SetName("retrying")
+
+	envelopes, err := spanToEnvelopes(newTestResource(), newTestInstrumentationLibrary(), span, 100, zap.NewNop())
+
+	require.NoError(t, err)
+	// The span itself, plus one envelope per event.
+	require.Len(t, envelopes, 3)
+}
+
+func TestSpanToEnvelopes_ParentSpanID(t *testing.T) {
+	span := newTestSpanWithEvents(t, 0)
+	span.SetParentSpanID(pdata.NewSpanID([8]byte{7}))
+
+	envelopes, err := spanToEnvelopes(newTestResource(), newTestInstrumentationLibrary(), span, 100, zap.NewNop())
+	require.NoError(t, err)
+	require.Len(t, envelopes, 1)
+
+	assert.Equal(t, span.ParentSpanID().HexString(), envelopes[0].Tags[contracts.OperationParentId])
+}
+
+func TestSpanToEnvelopes_RootSpanOmitsParentID(t *testing.T) {
+	span := newTestSpanWithEvents(t, 0)
+
+	envelopes, err := spanToEnvelopes(newTestResource(), newTestInstrumentationLibrary(), span, 100, zap.NewNop())
+	require.NoError(t, err)
+	require.Len(t, envelopes, 1)
+
+	_, found := envelopes[0].Tags[contracts.OperationParentId]
+	assert.False(t, found)
+}
+
+func TestLinksToProperty(t *testing.T) {
+	span := newTestSpanWithEvents(t, 0)
+	span.Links().Resize(1)
+	link := span.Links().At(0)
+	link.SetTraceID(traceIDFromByte(5))
+	link.SetSpanID(pdata.NewSpanID([8]byte{2}))
+
+	envelopes, err := spanToEnvelopes(newTestResource(), newTestInstrumentationLibrary(), span, 100, zap.NewNop())
+	require.NoError(t, err)
+	require.Len(t, envelopes, 1)
+
+	data, ok := envelopes[0].Data.(*contracts.Data)
+	require.True(t, ok)
+	dependency, ok := data.BaseData.(*contracts.RemoteDependencyData)
+	require.True(t, ok)
+
+	links, found := dependency.Properties[msLinksProperty]
+	require.True(t, found)
+	assert.Contains(t, links, link.SpanID().HexString())
+}
+
+func TestLinksToProperty_NoLinks(t *testing.T) {
+	assert.Equal(t, "", linksToProperty(pdata.NewSpanLinkSlice()))
+}
@@ -0,0 +1,29 @@
+// Copyright OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azuremonitorexporter
+
+import (
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+)
+
+// transportChannel is the interface that is used to send data to Azure Monitor.
+// appinsights.TelemetryChannel already satisfies this and is used as the default
+// implementation; it is factored out as an interface so tests can substitute a mock.
+type transportChannel interface {
+	Send(envelope *appinsights.Envelope)
+	Close(timeout time.Duration) <-chan struct{}
+}